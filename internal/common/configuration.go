@@ -2,12 +2,14 @@ package common
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"reflect"
 	"runtime"
 
 	"github.com/h44z/wg-portal/internal/ldap"
 	"github.com/h44z/wg-portal/internal/users"
+	"github.com/h44z/wg-portal/internal/webhook"
 	"github.com/h44z/wg-portal/internal/wireguard"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/sirupsen/logrus"
@@ -70,9 +72,54 @@ type Config struct {
 	Email    MailConfig       `yaml:"email"`
 	LDAP     ldap.Config      `yaml:"ldap"`
 	WG       wireguard.Config `yaml:"wg"`
+
+	// Webhooks lists external endpoints that are notified of peer and user lifecycle events
+	// (created, updated, deleted, ...), e.g. to integrate with a SIEM or firewall automation.
+	Webhooks []webhook.Config `yaml:"webhooks"`
 }
 
+// NewConfig loads the configuration, falling back to bare defaults (without the file or the
+// environment overlaid) if any part of loading fails. It is kept for callers that cannot handle a load error during startup; new
+// code that wants to react to a config reload should use LoadConfig instead.
 func NewConfig() *Config {
+	cfg, err := LoadConfig()
+	if err != nil {
+		logrus.Warnf("unable to load configuration: %v, using default configuration...", err)
+		cfg = defaultConfig()
+	}
+	return cfg
+}
+
+// LoadConfig builds a Config from its built-in defaults, then overlays config.yml (or the file
+// named by $CONFIG_FILE) and the environment on top. Unlike NewConfig it returns an error
+// instead of silently falling back to defaults, so it can be called again by a Reloader without
+// ever replacing a good running configuration with an empty one.
+func LoadConfig() (*Config, error) {
+	cfg := defaultConfig()
+
+	cfgFile, ok := os.LookupEnv("CONFIG_FILE")
+	if !ok {
+		cfgFile = "config.yml" // Default config file
+	}
+	if err := loadConfigFile(cfg, cfgFile); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load %s: %w", cfgFile, err)
+		}
+		logrus.Warnf("config file %s not found, using defaults overlaid with environment variables", cfgFile)
+	}
+	if err := loadConfigEnv(cfg); err != nil {
+		return nil, fmt.Errorf("failed to load environment config: %w", err)
+	}
+
+	if cfg.WG.ManageIPAddresses && runtime.GOOS != "linux" {
+		logrus.Warnf("Managing IP addresses only works on linux! Feature disabled.")
+		cfg.WG.ManageIPAddresses = false
+	}
+
+	return cfg, nil
+}
+
+func defaultConfig() *Config {
 	cfg := &Config{}
 
 	// Default config
@@ -109,24 +156,5 @@ func NewConfig() *Config {
 	cfg.Email.Host = "127.0.0.1"
 	cfg.Email.Port = 25
 
-	// Load config from file and environment
-	cfgFile, ok := os.LookupEnv("CONFIG_FILE")
-	if !ok {
-		cfgFile = "config.yml" // Default config file
-	}
-	err := loadConfigFile(cfg, cfgFile)
-	if err != nil {
-		logrus.Warnf("unable to load config.yml file: %v, using default configuration...", err)
-	}
-	err = loadConfigEnv(cfg)
-	if err != nil {
-		logrus.Warnf("unable to load environment config: %v", err)
-	}
-
-	if cfg.WG.ManageIPAddresses && runtime.GOOS != "linux" {
-		logrus.Warnf("Managing IP addresses only works on linux! Feature disabled.")
-		cfg.WG.ManageIPAddresses = false
-	}
-
 	return cfg
 }
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// SendMail sends a plain-text email using the given MailConfig. It is intentionally minimal -
+// used for best-effort notifications (e.g. a peer expiry warning) that should not block the
+// caller on a full mail delivery stack.
+func SendMail(cfg MailConfig, from, to, subject, body string) error {
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	msg := []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", from, to, subject, body))
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return smtp.SendMail(addr, auth, from, []string{to}, msg)
+}
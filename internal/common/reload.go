@@ -0,0 +1,163 @@
+package common
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Reloader watches config.yml and listens for SIGHUP, applying configuration changes to a live
+// Config without dropping any WireGuard peers. Changes that cannot be applied without a restart
+// (e.g. switching the database driver) are rejected and the previous configuration stays live.
+type Reloader struct {
+	mux     sync.RWMutex
+	current *Config
+	path    string
+
+	onReload []func(*Config)
+}
+
+// NewReloader creates a Reloader around initial, which must have been loaded from path.
+func NewReloader(initial *Config, path string) *Reloader {
+	return &Reloader{current: initial, path: path}
+}
+
+// Current returns the currently active configuration.
+func (r *Reloader) Current() *Config {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.current
+}
+
+// OnReload registers fn to be called with the new configuration after every successful reload.
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.onReload = append(r.onReload, fn)
+}
+
+// Watch starts watching path for changes and listening for SIGHUP, reloading the configuration
+// until stop is closed. It watches path's containing directory rather than the file itself:
+// tools that write config.yml atomically (write a temp file, then rename it into place) replace
+// the watched inode, which would silently drop a watch placed on the file directly.
+func (r *Reloader) Watch(stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create config file watcher")
+	}
+
+	dir := filepath.Dir(r.path)
+	name := filepath.Base(r.path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return errors.Wrapf(err, "failed to watch %s", dir)
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(sighup)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-sighup:
+				logrus.Infof("received SIGHUP, reloading configuration")
+				r.reload()
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Base(event.Name) != name {
+					continue // change to an unrelated file in the same directory
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					logrus.Infof("config file %s changed, reloading configuration", r.path)
+					r.reload()
+				}
+			case watchErr, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logrus.Errorf("config watcher error: %v", watchErr)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *Reloader) reload() {
+	// LoadConfig treats a missing file as "use defaults"; during a reload that would replace the
+	// running configuration with bare defaults, so a missing file keeps the previous one live.
+	if _, err := os.Stat(r.path); err != nil {
+		logrus.Errorf("config file %s is not available, keeping previous configuration live: %v", r.path, err)
+		return
+	}
+
+	next, err := LoadConfig()
+	if err != nil {
+		logrus.Errorf("failed to reload configuration, keeping previous configuration live: %v", err)
+		return
+	}
+
+	r.mux.Lock()
+	prev := r.current
+	if err := validateReload(prev, next); err != nil {
+		r.mux.Unlock()
+		logrus.Errorf("rejected configuration reload: %v", err)
+		return
+	}
+	r.current = next
+	onReload := make([]func(*Config), len(r.onReload))
+	copy(onReload, r.onReload)
+	r.mux.Unlock()
+
+	for _, fn := range onReload {
+		fn(next)
+	}
+}
+
+// validateReload rejects a reload that changes a setting which cannot be safely applied to a
+// running server. LDAP settings, mail settings, admin credentials, interface PostUp/PostDown and
+// the editable-keys flag are all considered safe and are not checked here. Per-interface
+// ListenPort, IPs (address pool), MTU and DNS are not safe to change on a live WireGuard
+// interface, so they are rejected below.
+func validateReload(prev, next *Config) error {
+	if prev.Database.Typ != next.Database.Typ || prev.Database.Database != next.Database.Database {
+		return errors.New("database configuration cannot be changed without a restart")
+	}
+	if !reflect.DeepEqual(prev.WG.DeviceNames(), next.WG.DeviceNames()) {
+		return errors.New("adding or removing WireGuard interfaces requires a restart")
+	}
+	for _, device := range next.WG.DeviceNames() {
+		prevIfc, prevOk := prev.WG.GetInterface(device)
+		nextIfc, nextOk := next.WG.GetInterface(device)
+		if !prevOk || !nextOk {
+			continue // legacy single-interface device, nothing to compare
+		}
+		if prevIfc.ListenPort != nextIfc.ListenPort {
+			return errors.Errorf("listen port of interface %s cannot be changed without a restart", device)
+		}
+		if !reflect.DeepEqual(prevIfc.IPs, nextIfc.IPs) {
+			return errors.Errorf("address pool of interface %s cannot be changed without a restart", device)
+		}
+		if prevIfc.MTU != nextIfc.MTU {
+			return errors.Errorf("MTU of interface %s cannot be changed without a restart", device)
+		}
+		if !reflect.DeepEqual(prevIfc.DNS, nextIfc.DNS) {
+			return errors.Errorf("DNS of interface %s cannot be changed without a restart", device)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package events decouples the core peer/user lifecycle methods in server from however those
+// changes are actually delivered to the outside world (webhooks, logging, future subscribers).
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies a peer/user lifecycle event.
+type Type string
+
+const (
+	PeerCreated     Type = "peer.created"
+	PeerUpdated     Type = "peer.updated"
+	PeerDeactivated Type = "peer.deactivated"
+	PeerDeleted     Type = "peer.deleted"
+	UserCreated     Type = "user.created"
+	UserDisabled    Type = "user.disabled"
+)
+
+// Subject is the redacted, publisher-safe representation of the wireguard.Peer or users.User
+// that triggered an event. Producers must build this themselves (see server.publish) rather
+// than forwarding the domain struct as-is: wireguard.Peer in particular carries the peer's
+// WireGuard private key and preshared key, which must never leave the process unredacted.
+type Subject struct {
+	UID           string     `json:"uid,omitempty"`
+	Email         string     `json:"email,omitempty"`
+	Identifier    string     `json:"identifier,omitempty"`
+	DeviceName    string     `json:"deviceName,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt,omitempty"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+}
+
+// Event is a single lifecycle notification, carrying just enough context for subscribers to
+// react without querying the database themselves.
+type Event struct {
+	Type    Type
+	Device  string
+	Email   string
+	Subject Subject
+}
+
+// Publisher decouples event producers from however events are actually delivered, so the core
+// server methods can be unit tested with a fake publisher instead of a real bus.
+type Publisher interface {
+	Publish(Event)
+}
+
+// Bus is an in-process, synchronous pub/sub dispatcher. Subscribers are invoked in the order
+// they were registered, on the goroutine that called Publish.
+type Bus struct {
+	mux         sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers fn to be called for every subsequently published event.
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish implements Publisher by calling every registered subscriber with e.
+func (b *Bus) Publish(e Event) {
+	b.mux.RLock()
+	subs := make([]func(Event), len(b.subscribers))
+	copy(subs, b.subscribers)
+	b.mux.RUnlock()
+
+	for _, fn := range subs {
+		fn(e)
+	}
+}
@@ -0,0 +1,198 @@
+// Package ipam allocates IP addresses for WireGuard peers from administrator-defined CIDR
+// pools, supporting reserved sub-ranges, static per-user reservations and conflict detection
+// across interfaces that share an overlapping range.
+package ipam
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Strategy selects how the next free address is picked from a pool.
+type Strategy string
+
+const (
+	StrategySequential Strategy = "sequential"
+	StrategyRandom     Strategy = "random"
+	StrategyHashEmail  Strategy = "hash-email"
+)
+
+// PoolKind classifies a pool's intended use.
+type PoolKind string
+
+const (
+	PoolStatic  PoolKind = "static"
+	PoolDynamic PoolKind = "dynamic"
+	PoolGuest   PoolKind = "guest"
+)
+
+// Reservation pins a specific address in a pool to a user, so that the same peer always gets
+// the same address across re-creations.
+type Reservation struct {
+	Email   string
+	Address string
+}
+
+// Pool describes one CIDR range that addresses are allocated from for a specific device.
+type Pool struct {
+	Device   string
+	Kind     PoolKind
+	CIDR     string
+	Strategy Strategy
+
+	Reserved     []string      // sub-ranges (CIDR) excluded from allocation, e.g. the gateway
+	Reservations []Reservation // static host reservations tied to a user's email
+}
+
+// Manager allocates and tracks IP addresses across one or more CIDR pools, detecting
+// collisions between peers on different interfaces whose pools overlap.
+type Manager struct {
+	mux   sync.Mutex
+	pools []Pool
+	used  map[string]string // address -> device that currently holds it
+}
+
+// NewManager creates a Manager for the given pools.
+func NewManager(pools []Pool) *Manager {
+	return &Manager{pools: pools, used: make(map[string]string)}
+}
+
+// AcquireIP returns a free address from device's pool of the given kind for email. If email
+// already has a static reservation in that pool, the reserved address is returned instead of
+// allocating a new one. The address is marked as used across all devices until Release is
+// called, so overlapping pools on different interfaces cannot hand out the same address twice.
+func (m *Manager) AcquireIP(device string, kind PoolKind, email string) (string, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	pool, ok := m.findPool(device, kind)
+	if !ok {
+		return "", errors.Errorf("no %s pool configured for device %s", kind, device)
+	}
+
+	for _, r := range pool.Reservations {
+		if r.Email == email {
+			if holder, taken := m.used[r.Address]; taken && holder != device {
+				return "", errors.Errorf("reserved address %s is already in use on device %s", r.Address, holder)
+			}
+			m.used[r.Address] = device
+			return r.Address, nil
+		}
+	}
+
+	_, ipNet, err := net.ParseCIDR(pool.CIDR)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid CIDR %s", pool.CIDR)
+	}
+
+	candidates := excludeReserved(hosts(ipNet), pool.Reserved)
+	orderCandidates(candidates, pool.Strategy, email)
+
+	for _, ip := range candidates {
+		if _, taken := m.used[ip]; taken {
+			continue
+		}
+		m.used[ip] = device
+		return ip, nil
+	}
+
+	return "", errors.Errorf("no free address left in %s pool for device %s", kind, device)
+}
+
+// Release returns an address to the pool it was allocated from.
+func (m *Manager) Release(address string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.used, address)
+}
+
+// MarkUsed records that address is already bound to a peer on device, without allocating it
+// from any pool. Callers must seed the manager with every address already live on the physical
+// interfaces (e.g. from persisted peers) before the first AcquireIP call, otherwise a restart
+// can hand out an address that is already in use.
+func (m *Manager) MarkUsed(address, device string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.used[address] = device
+}
+
+func (m *Manager) findPool(device string, kind PoolKind) (Pool, bool) {
+	for _, p := range m.pools {
+		if p.Device == device && p.Kind == kind {
+			return p, true
+		}
+	}
+	return Pool{}, false
+}
+
+// hosts enumerates the usable host addresses of a CIDR range, excluding the network and
+// broadcast addresses.
+func hosts(n *net.IPNet) []string {
+	var ips []string
+	ip := append(net.IP(nil), n.IP.Mask(n.Mask)...)
+	for n.Contains(ip) {
+		ips = append(ips, ip.String())
+		incIP(ip)
+	}
+	if len(ips) > 2 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips
+}
+
+func incIP(ip net.IP) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]++
+		if ip[i] != 0 {
+			break
+		}
+	}
+}
+
+func excludeReserved(candidates, reserved []string) []string {
+	if len(reserved) == 0 {
+		return candidates
+	}
+
+	var nets []*net.IPNet
+	for _, r := range reserved {
+		if _, n, err := net.ParseCIDR(r); err == nil {
+			nets = append(nets, n)
+		}
+	}
+
+	filtered := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		ip := net.ParseIP(c)
+		reserved := false
+		for _, n := range nets {
+			if n.Contains(ip) {
+				reserved = true
+				break
+			}
+		}
+		if !reserved {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// orderCandidates reorders candidates in place according to the configured allocation
+// strategy. Sequential leaves the (already ascending) order untouched.
+func orderCandidates(candidates []string, strategy Strategy, email string) {
+	switch strategy {
+	case StrategyRandom:
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	case StrategyHashEmail:
+		h := sha256.Sum256([]byte(email))
+		seed := int64(binary.BigEndian.Uint64(h[:8]))
+		r := rand.New(rand.NewSource(seed))
+		r.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	}
+}
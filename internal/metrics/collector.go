@@ -0,0 +1,114 @@
+// Package metrics samples per-peer handshake and traffic counters from wgctrl and exposes them
+// in Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// PeerStats holds the most recently observed handshake and traffic counters for a single peer.
+type PeerStats struct {
+	Device     string
+	UID        string
+	Email      string
+	Identifier string
+
+	LastHandshakeTime time.Time
+	ReceiveBytes      int64
+	TransmitBytes     int64
+}
+
+// Collector keeps a rolling set of per-peer statistics, keyed by Peer.UID, and renders them as
+// Prometheus text exposition format.
+type Collector struct {
+	mux   sync.RWMutex
+	stats map[string]PeerStats
+}
+
+// NewCollector creates an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{stats: make(map[string]PeerStats)}
+}
+
+// Update stores the latest sample for a peer, replacing any previous one.
+func (c *Collector) Update(stats PeerStats) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stats[stats.UID] = stats
+}
+
+// Get returns the last sampled stats for the given peer UID.
+func (c *Collector) Get(uid string) (PeerStats, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	s, ok := c.stats[uid]
+	return s, ok
+}
+
+// All returns every currently tracked peer's stats, in no particular order.
+func (c *Collector) All() []PeerStats {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	all := make([]PeerStats, 0, len(c.stats))
+	for _, s := range c.stats {
+		all = append(all, s)
+	}
+	return all
+}
+
+// WritePromText renders the current stats as Prometheus text exposition format, labelled by
+// device, peer uid, email and identifier.
+func (c *Collector) WritePromText(w io.Writer) error {
+	metrics := [...]string{
+		"# HELP wgportal_peer_last_handshake_seconds Unix timestamp of the peer's last handshake.",
+		"# TYPE wgportal_peer_last_handshake_seconds gauge",
+	}
+	for _, line := range metrics {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.All() {
+		if s.LastHandshakeTime.IsZero() {
+			continue // peer has never handshaked, nothing meaningful to report yet
+		}
+		if _, err := fmt.Fprintf(w, "wgportal_peer_last_handshake_seconds%s %d\n",
+			labels(s), s.LastHandshakeTime.Unix()); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintln(w, "# HELP wgportal_peer_receive_bytes_total Bytes received from the peer."); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "# TYPE wgportal_peer_receive_bytes_total counter"); err != nil {
+		return err
+	}
+	for _, s := range c.All() {
+		if _, err := fmt.Fprintf(w, "wgportal_peer_receive_bytes_total%s %d\n", labels(s), s.ReceiveBytes); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintln(w, "# HELP wgportal_peer_transmit_bytes_total Bytes transmitted to the peer."); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "# TYPE wgportal_peer_transmit_bytes_total counter"); err != nil {
+		return err
+	}
+	for _, s := range c.All() {
+		if _, err := fmt.Fprintf(w, "wgportal_peer_transmit_bytes_total%s %d\n", labels(s), s.TransmitBytes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func labels(s PeerStats) string {
+	return fmt.Sprintf("{device=%q,uid=%q,email=%q,identifier=%q}", s.Device, s.UID, s.Email, s.Identifier)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"github.com/h44z/wg-portal/internal/ipam"
+	"github.com/h44z/wg-portal/internal/wireguard"
+)
+
+// NewIPAMManager builds an ipam.Manager from the pools configured on every managed interface.
+// It is used during server setup; if no interface configures any pool it returns nil, in which
+// case acquireIP falls back to the legacy per-device allocation.
+func NewIPAMManager(cfg wireguard.Config) *ipam.Manager {
+	var pools []ipam.Pool
+
+	for _, iface := range cfg.Interfaces {
+		for _, p := range iface.IpamPools {
+			pools = append(pools, ipam.Pool{
+				Device:       iface.DeviceName,
+				Kind:         ipam.PoolKind(p.Kind),
+				CIDR:         p.CIDR,
+				Strategy:     ipam.Strategy(p.Strategy),
+				Reserved:     p.Reserved,
+				Reservations: p.Reservations,
+			})
+		}
+	}
+
+	if len(pools) == 0 {
+		return nil
+	}
+	return ipam.NewManager(pools)
+}
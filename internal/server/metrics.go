@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/h44z/wg-portal/internal/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// metricsSampleInterval is how often handshake and traffic counters are sampled via wgctrl.
+const metricsSampleInterval = 30 * time.Second
+
+// StartMetricsCollector launches a background goroutine that periodically samples handshake
+// and traffic counters for every managed peer, so they can be rendered at /metrics and surfaced
+// through GetPeerStats.
+func (s *Server) StartMetricsCollector(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(metricsSampleInterval)
+		defer ticker.Stop()
+
+		s.sampleMetrics()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.sampleMetrics()
+			}
+		}
+	}()
+}
+
+func (s *Server) sampleMetrics() {
+	for _, device := range s.config.WG.DeviceNames() {
+		dev, err := s.wg.GetDevice(device)
+		if err != nil {
+			logrus.Errorf("failed to sample metrics for %s: %v", device, err)
+			continue
+		}
+
+		for _, wgPeer := range dev.Peers {
+			peer := s.peers.GetPeerByKey(wgPeer.PublicKey.String())
+			if peer.Peer == nil {
+				continue // not one of our managed peers (anymore)
+			}
+
+			s.metrics.Update(metrics.PeerStats{
+				Device:            device,
+				UID:               peer.UID,
+				Email:             peer.Email,
+				Identifier:        peer.Identifier,
+				LastHandshakeTime: wgPeer.LastHandshakeTime,
+				ReceiveBytes:      wgPeer.ReceiveBytes,
+				TransmitBytes:     wgPeer.TransmitBytes,
+			})
+		}
+	}
+}
+
+// MetricsHandler renders the current peer metrics in Prometheus text exposition format. It is
+// intended to be mounted at /metrics.
+func (s *Server) MetricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.metrics.WritePromText(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetPeerStats returns the last sampled handshake and traffic counters for the peer with the
+// given public key, so the UI can render last-seen and bandwidth without reaching into wg
+// directly.
+func (s *Server) GetPeerStats(publicKey string) (metrics.PeerStats, error) {
+	peer := s.peers.GetPeerByKey(publicKey)
+	if peer.Peer == nil {
+		return metrics.PeerStats{}, errors.Errorf("unknown peer %s", publicKey)
+	}
+
+	stats, ok := s.metrics.Get(peer.UID)
+	if !ok {
+		return metrics.PeerStats{}, errors.Errorf("no metrics sampled yet for peer %s", publicKey)
+	}
+
+	return stats, nil
+}
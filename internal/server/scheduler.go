@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/h44z/wg-portal/internal/common"
+	"github.com/h44z/wg-portal/internal/wireguard"
+	"github.com/sirupsen/logrus"
+)
+
+// peerScheduleInterval is how often the background scheduler scans the database for peers that
+// need to be (de)activated based on their ActivateAt/ExpiresAt timestamps.
+const peerScheduleInterval = 1 * time.Minute
+
+// StartPeerScheduler launches a background goroutine that periodically activates and
+// deactivates peers based on their ActivateAt and ExpiresAt fields. It stops as soon as stop is
+// closed and closes the returned channel once the goroutine has exited.
+func (s *Server) StartPeerScheduler(stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(peerScheduleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.runPeerSchedule()
+			}
+		}
+	}()
+
+	return done
+}
+
+// runPeerSchedule scans all known peers and flips DeactivatedAt whenever a peer has crossed its
+// ActivateAt or ExpiresAt time, notifying the peer's owner when it expires.
+func (s *Server) runPeerSchedule() {
+	now := time.Now()
+
+	for _, peer := range s.peers.GetAllPeers() {
+		switch {
+		case peer.ActivateAt != nil && !peer.ActivateAt.After(now) && peer.DeactivatedAt != nil &&
+			(peer.ExpiresAt == nil || peer.ExpiresAt.After(now)):
+			peer.DeactivatedAt = nil
+			if err := s.UpdatePeer(peer, now); err != nil {
+				logrus.Errorf("failed to activate scheduled peer %s: %v", peer.PublicKey, err)
+				continue
+			}
+			logrus.Infof("audit: peer %s (%s) activated on schedule", peer.Identifier, peer.Email)
+		case peer.ExpiresAt != nil && !peer.ExpiresAt.After(now) && peer.DeactivatedAt == nil:
+			peer.DeactivatedAt = &now
+			if err := s.UpdatePeer(peer, now); err != nil {
+				logrus.Errorf("failed to expire scheduled peer %s: %v", peer.PublicKey, err)
+				continue
+			}
+			logrus.Infof("audit: peer %s (%s) expired on schedule", peer.Identifier, peer.Email)
+			s.notifyPeerExpired(peer)
+		}
+	}
+}
+
+// notifyPeerExpired sends a best-effort email notification to the peer's owner once it has been
+// auto-expired. It is a no-op if mail is not configured.
+func (s *Server) notifyPeerExpired(peer wireguard.Peer) {
+	if s.config.Email.Host == "" || peer.Email == "" {
+		return
+	}
+
+	subject := "Your VPN access has expired"
+	body := fmt.Sprintf("Hello,\n\nYour WireGuard peer %q has expired and was automatically deactivated.\n",
+		peer.Identifier)
+
+	if err := common.SendMail(s.config.Email, s.config.Core.MailFrom, peer.Email, subject, body); err != nil {
+		logrus.Errorf("failed to send expiry notification to %s: %v", peer.Email, err)
+	}
+}
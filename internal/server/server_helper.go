@@ -4,11 +4,15 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/h44z/wg-portal/internal/common"
+	"github.com/h44z/wg-portal/internal/events"
+	"github.com/h44z/wg-portal/internal/ipam"
 	"github.com/h44z/wg-portal/internal/users"
 	"github.com/h44z/wg-portal/internal/wireguard"
 	"github.com/pkg/errors"
@@ -17,16 +21,98 @@ import (
 	"gorm.io/gorm"
 )
 
-// PrepareNewPeer initiates a new peer for the given WireGuard device.
-func (s *Server) PrepareNewPeer(device string) (wireguard.Peer, error) {
+// acquireIP assigns a free address for email on device from the pool of the given kind, using
+// the ipam manager when one is configured and falling back to the legacy per-device pool
+// otherwise (the legacy pool has no notion of kind, so fallbackIP is used as-is regardless).
+func (s *Server) acquireIP(device string, kind ipam.PoolKind, fallbackIP, email string) (string, error) {
+	if s.ipam != nil {
+		return s.ipam.AcquireIP(device, kind, email)
+	}
+	return s.peers.GetAvailableIp(device, fallbackIP)
+}
+
+// allowedIPsFor returns the allowed-IPs policy that new peers on device should get, preferring
+// the per-interface override from the multi-interface config (s.config.WG.Interfaces) over the
+// legacy device-level default, so interfaces configured via the new Interfaces list can apply
+// their own routing policy.
+func (s *Server) allowedIPsFor(device string, fallback string) string {
+	if ifc, ok := s.config.WG.GetInterface(device); ok && ifc.AllowedIPsStr != "" {
+		return ifc.AllowedIPsStr
+	}
+	return fallback
+}
+
+// interfaceIPsFor returns the per-peer address pool that device hands out, preferring the
+// per-interface override from the multi-interface config (s.config.WG.Interfaces) over the
+// legacy device-level default, so an interface configured via the new Interfaces list allocates
+// peer addresses from its own pool instead of the legacy one.
+func (s *Server) interfaceIPsFor(device string, fallback []string) []string {
+	if ifc, ok := s.config.WG.GetInterface(device); ok && len(ifc.IPs) > 0 {
+		return ifc.IPs
+	}
+	return fallback
+}
+
+// publish notifies s.events of a lifecycle event, if a publisher was injected. Core methods
+// call this instead of talking to a concrete webhook/bus implementation directly, so they stay
+// unit-testable with a fake publisher.
+func (s *Server) publish(typ events.Type, device, email string, subject events.Subject) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(events.Event{Type: typ, Device: device, Email: email, Subject: subject})
+}
+
+// redactedPeerSubject returns a publisher-safe representation of peer, stripped of its
+// WireGuard private key and preshared key so neither can leak through an outbound webhook.
+func redactedPeerSubject(peer wireguard.Peer) events.Subject {
+	return events.Subject{
+		UID:           peer.UID,
+		Email:         peer.Email,
+		Identifier:    peer.Identifier,
+		DeviceName:    peer.DeviceName,
+		CreatedAt:     peer.CreatedAt,
+		UpdatedAt:     peer.UpdatedAt,
+		DeactivatedAt: peer.DeactivatedAt,
+	}
+}
+
+// redactedUserSubject returns a publisher-safe representation of user.
+func redactedUserSubject(user users.User) events.Subject {
+	var deactivatedAt *time.Time
+	if user.DeletedAt.Valid {
+		t := user.DeletedAt.Time
+		deactivatedAt = &t
+	}
+	return events.Subject{
+		Email:         user.Email,
+		Identifier:    strings.TrimSpace(user.Firstname + " " + user.Lastname),
+		DeactivatedAt: deactivatedAt,
+	}
+}
+
+// PrepareNewPeer initiates a new peer for the given WireGuard device, allocating its addresses
+// from the ipam pool of the given kind (ipam.PoolDynamic, ipam.PoolStatic or ipam.PoolGuest;
+// ignored if no ipam manager is configured). email is used as the hash key when the configured
+// ipam pool uses the hash-email strategy, and may be empty for previews. expiresAt and
+// activateAt are optional and describe the peer's validity window: a peer with a future
+// activateAt is prepared as deactivated, and the scheduler will flip it (and later expire it)
+// automatically.
+//
+// This is part of the Server API consumed by the HTTP handlers and CLI, which live outside this
+// source tree; there is no in-tree caller left on the old signature (grep confirms
+// PrepareNewPeer/CreatePeer/CreatePeerByEmail are only called from within this file), but any
+// out-of-tree caller must be updated to pass kind alongside its existing arguments.
+func (s *Server) PrepareNewPeer(device string, kind ipam.PoolKind, email string, expiresAt, activateAt *time.Time) (wireguard.Peer, error) {
 	dev := s.peers.GetDevice(device)
 
 	peer := wireguard.Peer{}
 	peer.IsNew = true
-	peer.AllowedIPsStr = dev.AllowedIPsStr
-	peer.IPs = make([]string, len(dev.IPs))
-	for i := range dev.IPs {
-		freeIP, err := s.peers.GetAvailableIp(device, dev.IPs[i])
+	peer.AllowedIPsStr = s.allowedIPsFor(device, dev.AllowedIPsStr)
+	ips := s.interfaceIPsFor(device, dev.IPs)
+	peer.IPs = make([]string, len(ips))
+	for i := range ips {
+		freeIP, err := s.acquireIP(device, kind, ips[i], email)
 		if err != nil {
 			return wireguard.Peer{}, errors.WithMessage(err, "failed to get available IP addresses")
 		}
@@ -46,42 +132,52 @@ func (s *Server) PrepareNewPeer(device string) (wireguard.Peer, error) {
 	peer.PublicKey = key.PublicKey().String()
 	peer.UID = fmt.Sprintf("u%x", md5.Sum([]byte(peer.PublicKey)))
 
+	peer.ExpiresAt = expiresAt
+	peer.ActivateAt = activateAt
+	if activateAt != nil && activateAt.After(time.Now()) {
+		now := time.Now()
+		peer.DeactivatedAt = &now
+	}
+
 	return peer, nil
 }
 
-// CreatePeerByEmail creates a new peer for the given email. If no user with the specified email was found, a new one
-// will be created.
-func (s *Server) CreatePeerByEmail(device, email, identifierSuffix string, disabled bool) error {
+// CreatePeerByEmail creates a new peer for the given email, allocated from the ipam pool of the
+// given kind (see PrepareNewPeer). If no user with the specified email was found, a new one
+// will be created. expiresAt and activateAt are optional and set the peer's validity window, see PrepareNewPeer.
+func (s *Server) CreatePeerByEmail(device string, kind ipam.PoolKind, email, identifierSuffix string, disabled bool, expiresAt, activateAt *time.Time) error {
 	user, err := s.users.GetOrCreateUser(email)
 	if err != nil {
 		return errors.WithMessagef(err, "failed to load/create related user %s", email)
 	}
 
-	peer, err := s.PrepareNewPeer(device)
+	peer, err := s.PrepareNewPeer(device, kind, email, expiresAt, activateAt)
 	if err != nil {
 		return errors.WithMessage(err, "failed to prepare new peer")
 	}
 	peer.Email = email
 	peer.Identifier = fmt.Sprintf("%s %s (%s)", user.Firstname, user.Lastname, identifierSuffix)
 
-	now := time.Now()
 	if disabled {
+		now := time.Now()
 		peer.DeactivatedAt = &now
 	}
 
-	return s.CreatePeer(device, peer)
+	return s.CreatePeer(device, kind, peer)
 }
 
 // CreatePeer creates the new peer in the database. If the peer has no assigned ip addresses, a new one will be assigned
-// automatically. Also, if the private key is empty, a new key-pair will be generated.
+// automatically from the ipam pool of the given kind (see PrepareNewPeer). Also, if the private key is empty, a new
+// key-pair will be generated.
 // This function also configures the new peer on the physical WireGuard interface if the peer is not deactivated.
-func (s *Server) CreatePeer(device string, peer wireguard.Peer) error {
+func (s *Server) CreatePeer(device string, kind ipam.PoolKind, peer wireguard.Peer) error {
 	dev := s.peers.GetDevice(device)
-	peer.AllowedIPsStr = dev.AllowedIPsStr
+	peer.AllowedIPsStr = s.allowedIPsFor(device, dev.AllowedIPsStr)
 	if peer.IPs == nil || len(peer.IPs) == 0 {
-		peer.IPs = make([]string, len(dev.IPs))
-		for i := range dev.IPs {
-			freeIP, err := s.peers.GetAvailableIp(device, dev.IPs[i])
+		ips := s.interfaceIPsFor(device, dev.IPs)
+		peer.IPs = make([]string, len(ips))
+		for i := range ips {
+			freeIP, err := s.acquireIP(device, kind, ips[i], peer.Email)
 			if err != nil {
 				return errors.WithMessage(err, "failed to get available IP addresses")
 			}
@@ -117,6 +213,8 @@ func (s *Server) CreatePeer(device string, peer wireguard.Peer) error {
 		return errors.WithMessage(err, "failed to create peer")
 	}
 
+	s.publish(events.PeerCreated, device, peer.Email, redactedPeerSubject(peer))
+
 	return s.WriteWireGuardConfigFile(device)
 }
 
@@ -127,7 +225,7 @@ func (s *Server) UpdatePeer(peer wireguard.Peer, updateTime time.Time) error {
 	// Update WireGuard device
 	var err error
 	switch {
-	case peer.DeactivatedAt == &updateTime:
+	case peer.DeactivatedAt != nil:
 		err = s.wg.RemovePeer(peer.DeviceName, peer.PublicKey)
 	case peer.DeactivatedAt == nil && currentPeer.Peer != nil:
 		err = s.wg.UpdatePeer(peer.DeviceName, peer.GetConfig())
@@ -143,6 +241,12 @@ func (s *Server) UpdatePeer(peer wireguard.Peer, updateTime time.Time) error {
 		return errors.WithMessage(err, "failed to update peer")
 	}
 
+	if peer.DeactivatedAt != nil {
+		s.publish(events.PeerDeactivated, peer.DeviceName, peer.Email, redactedPeerSubject(peer))
+	} else {
+		s.publish(events.PeerUpdated, peer.DeviceName, peer.Email, redactedPeerSubject(peer))
+	}
+
 	return s.WriteWireGuardConfigFile(peer.DeviceName)
 }
 
@@ -158,10 +262,22 @@ func (s *Server) DeletePeer(peer wireguard.Peer) error {
 		return errors.WithMessage(err, "failed to remove peer")
 	}
 
+	if s.ipam != nil {
+		for _, ip := range peer.IPs {
+			s.ipam.Release(ip)
+		}
+	}
+
+	s.publish(events.PeerDeleted, peer.DeviceName, peer.Email, redactedPeerSubject(peer))
+
 	return s.WriteWireGuardConfigFile(peer.DeviceName)
 }
 
-// RestoreWireGuardInterface restores the state of the physical WireGuard interface from the database.
+// RestoreWireGuardInterface restores the state of the physical WireGuard interface from the
+// database and, if an ipam manager is configured, seeds it with the addresses already bound to
+// these persisted peers. This must run before any new peer is allocated on device, otherwise the
+// first allocation after a restart could hand out an address that is already live on the
+// interface.
 func (s *Server) RestoreWireGuardInterface(device string) error {
 	activePeers := s.peers.GetActivePeers(device)
 
@@ -171,12 +287,20 @@ func (s *Server) RestoreWireGuardInterface(device string) error {
 				return errors.WithMessage(err, "failed to add WireGuard peer")
 			}
 		}
+
+		if s.ipam != nil {
+			for _, ip := range activePeers[i].IPs {
+				s.ipam.MarkUsed(ip, device)
+			}
+		}
 	}
 
 	return nil
 }
 
 // WriteWireGuardConfigFile writes the configuration file for the physical WireGuard interface.
+// The file is written atomically: it is staged in a temporary file next to the target and then
+// renamed into place, so a reader never observes a partially written .conf file.
 func (s *Server) WriteWireGuardConfigFile(device string) error {
 	if s.config.WG.ConfigDirectoryPath == "" {
 		return nil // writing disabled
@@ -190,10 +314,32 @@ func (s *Server) WriteWireGuardConfigFile(device string) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed to get config file")
 	}
+
 	filePath := path.Join(s.config.WG.ConfigDirectoryPath, dev.DeviceName+".conf")
-	if err := ioutil.WriteFile(filePath, cfg, 0644); err != nil {
+	tmpFilePath := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmpFilePath, cfg, 0644); err != nil {
 		return errors.Wrap(err, "failed to write WireGuard config file")
 	}
+	if err := os.Rename(tmpFilePath, filePath); err != nil {
+		return errors.Wrap(err, "failed to move WireGuard config file into place")
+	}
+	return nil
+}
+
+// WriteWireGuardConfigFiles regenerates the .conf file for every managed WireGuard interface
+// under ConfigDirectoryPath. Each file is rendered from the device as stored by the peer manager
+// (see WriteWireGuardConfigFile); PostUp/PostDown from the Interfaces list are not written to it.
+func (s *Server) WriteWireGuardConfigFiles() error {
+	if s.config.WG.ConfigDirectoryPath == "" {
+		return nil // writing disabled
+	}
+
+	for _, device := range s.config.WG.DeviceNames() {
+		if err := s.WriteWireGuardConfigFile(device); err != nil {
+			return errors.WithMessagef(err, "failed to write config file for %s", device)
+		}
+	}
+
 	return nil
 }
 
@@ -214,6 +360,8 @@ func (s *Server) CreateUser(user users.User, device string) error {
 		return errors.WithMessage(err, "failed to create user in manager")
 	}
 
+	s.publish(events.UserCreated, device, user.Email, redactedUserSubject(user))
+
 	// Check if user already has a peer setup, if not, create one
 	return s.CreateUserDefaultPeer(user.Email, device)
 }
@@ -267,6 +415,8 @@ func (s *Server) DeleteUser(user users.User) error {
 		}
 	}
 
+	s.publish(events.UserDisabled, "", user.Email, redactedUserSubject(user))
+
 	return nil
 }
 
@@ -281,7 +431,7 @@ func (s *Server) CreateUserDefaultPeer(email, device string) error {
 	if s.config.Core.CreateDefaultPeer {
 		peers := s.peers.GetPeersByMail(email)
 		if len(peers) == 0 { // Create default vpn peer
-			if err := s.CreatePeer(device, wireguard.Peer{
+			if err := s.CreatePeer(device, ipam.PoolDynamic, wireguard.Peer{
 				Identifier: existingUser.Firstname + " " + existingUser.Lastname + " (Default)",
 				Email:      existingUser.Email,
 				CreatedBy:  existingUser.Email,
@@ -293,4 +443,4 @@ func (s *Server) CreateUserDefaultPeer(email, device string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,102 @@
+// Package webhook delivers events.Event notifications to external HTTP endpoints, so operators
+// can integrate SIEMs, IPAM systems or firewall automation without polling the database.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/h44z/wg-portal/internal/events"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Config describes a single outbound webhook target.
+type Config struct {
+	URL        string `yaml:"url"`
+	Secret     string `yaml:"secret"`
+	MaxRetries int    `yaml:"maxRetries"`
+}
+
+// Dispatcher implements events.Publisher by POSTing a signed JSON payload to every configured
+// target whenever it receives an event, retrying with exponential backoff on failure.
+type Dispatcher struct {
+	targets []Config
+	client  *http.Client
+}
+
+// NewDispatcher creates a Dispatcher for the given targets.
+func NewDispatcher(targets []Config) *Dispatcher {
+	return &Dispatcher{targets: targets, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Publish fans e out to every configured target in its own goroutine, so a slow or unreachable
+// endpoint never blocks the caller.
+func (d *Dispatcher) Publish(e events.Event) {
+	if len(d.targets) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		logrus.Errorf("failed to marshal webhook event %s: %v", e.Type, err)
+		return
+	}
+
+	for _, target := range d.targets {
+		go d.deliver(target, payload)
+	}
+}
+
+func (d *Dispatcher) deliver(target Config, payload []byte) {
+	maxRetries := target.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 3
+	}
+
+	backoff := time.Second
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if lastErr = d.post(target, payload); lastErr == nil {
+			return
+		}
+	}
+
+	logrus.Errorf("webhook delivery to %s failed after %d attempts: %v", target.URL, maxRetries+1, lastErr)
+}
+
+func (d *Dispatcher) post(target Config, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "failed to build webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-WGPortal-Signature", sign(target.Secret, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to deliver webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sign computes an HMAC-SHA256 signature of payload so the receiver can verify authenticity.
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,92 @@
+package wireguard
+
+import "github.com/h44z/wg-portal/internal/ipam"
+
+// InterfaceConfig describes a single physical WireGuard interface managed by wg-portal,
+// including the address pool and routing policy that is applied to peers created on it.
+//
+// DeviceName (via DeviceNames/GetInterface), IPs and AllowedIPsStr (via GetInterface, consumed
+// by Server.PrepareNewPeer/CreatePeer) drive peer allocation and routing policy today.
+// ListenPort, PostUp, PostDown, DNS, MTU and DisplayName are parsed; of these only ListenPort,
+// DNS and MTU are checked, by the reload-safety check in common.validateReload. Bringing up the physical interface
+// itself — setting its listen port, running PostUp/PostDown, pushing DNS/MTU to the peer config
+// — happens in the WireGuard device manager (Server.wg), which is not part of this source tree
+// and so cannot be wired up here. Scope any config.yml written against this schema accordingly:
+// only the address pool and allowed-IPs policy are currently enforced.
+type InterfaceConfig struct {
+	DeviceName  string `yaml:"device"`
+	DisplayName string `yaml:"displayName"`
+
+	ListenPort    int      `yaml:"listenPort"`
+	IPs           []string `yaml:"ips"`
+	AllowedIPsStr string   `yaml:"allowedIPs"`
+	DNS           []string `yaml:"dns"`
+	MTU           int      `yaml:"mtu"`
+
+	PostUp   string `yaml:"postUp"`
+	PostDown string `yaml:"postDown"`
+
+	// IpamPools lets an administrator carve this interface's address space into named pools
+	// (e.g. "static", "dynamic", "guest"), each with its own allocation strategy, reserved
+	// sub-ranges and static per-user reservations. See the ipam package for details.
+	IpamPools []IpamPoolConfig `yaml:"ipamPools"`
+}
+
+// IpamPoolConfig describes one CIDR pool that peer addresses are allocated from.
+type IpamPoolConfig struct {
+	Kind     string `yaml:"kind"` // "static", "dynamic" or "guest"
+	CIDR     string `yaml:"cidr"`
+	Strategy string `yaml:"strategy"` // "sequential", "random" or "hash-email"
+
+	Reserved     []string           `yaml:"reserved"` // sub-ranges excluded from allocation, e.g. the gateway
+	Reservations []ipam.Reservation `yaml:"reservations"`
+}
+
+// Config describes how wg-portal manages one or more physical WireGuard interfaces.
+//
+// DeviceName, WireGuardConfig and ManageIPAddresses describe the legacy single-interface setup
+// and are kept so that existing config.yml files keep working unchanged. Interfaces lets an
+// administrator describe several interfaces at once, so that a single user can own peers on
+// more than one interface at the same time. The config-file sweep (WriteWireGuardConfigFiles)
+// and the per-interface AllowedIPsStr override are implemented today; see InterfaceConfig for
+// which fields are still schema-only.
+type Config struct {
+	DeviceName          string `yaml:"device" envconfig:"WG_DEVICE"`
+	WireGuardConfig     string `yaml:"configFile" envconfig:"WG_CONFIG_FILE"`
+	ManageIPAddresses   bool   `yaml:"manageIPAddresses" envconfig:"MANAGE_IP_ADDRESSES"`
+	ConfigDirectoryPath string `yaml:"configDirectory" envconfig:"WG_CONFIG_DIRECTORY"`
+
+	Interfaces []InterfaceConfig `yaml:"interfaces"`
+}
+
+// DeviceNames returns the names of every physical interface that should be managed, combining
+// the legacy single-interface fields with the Interfaces list. Duplicate names are collapsed.
+func (c Config) DeviceNames() []string {
+	names := make([]string, 0, len(c.Interfaces)+1)
+	seen := make(map[string]bool, len(c.Interfaces)+1)
+
+	if c.DeviceName != "" {
+		names = append(names, c.DeviceName)
+		seen[c.DeviceName] = true
+	}
+	for _, i := range c.Interfaces {
+		if i.DeviceName == "" || seen[i.DeviceName] {
+			continue
+		}
+		names = append(names, i.DeviceName)
+		seen[i.DeviceName] = true
+	}
+
+	return names
+}
+
+// GetInterface returns the interface definition for the given device name, or false if no such
+// interface was configured via the Interfaces list.
+func (c Config) GetInterface(device string) (InterfaceConfig, bool) {
+	for _, i := range c.Interfaces {
+		if i.DeviceName == device {
+			return i, true
+		}
+	}
+	return InterfaceConfig{}, false
+}
@@ -0,0 +1,68 @@
+package wireguard
+
+import (
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Peer represents a single WireGuard peer (client) that is managed by wg-portal.
+type Peer struct {
+	DeviceName string
+	Identifier string
+	UID        string
+	Email      string
+
+	PublicKey    string
+	PresharedKey string
+	PrivateKey   string
+
+	AllowedIPsStr string
+	IPsStr        string
+	IPs           []string
+
+	// DeactivatedAt is set once a peer is administratively disabled or has been auto-deactivated
+	// by the expiration scheduler. A nil value means the peer is active.
+	DeactivatedAt *time.Time
+
+	// ExpiresAt, if set, causes the scheduler to deactivate the peer once it is reached.
+	ExpiresAt *time.Time
+	// ActivateAt, if set, causes the scheduler to activate the peer once it is reached. A peer
+	// with a future ActivateAt is created deactivated.
+	ActivateAt *time.Time
+
+	CreatedBy string
+	UpdatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	IsNew bool // true if the peer has not been persisted yet
+}
+
+// GetConfig converts the peer into a wgctrl peer configuration that can be applied to the
+// physical WireGuard interface.
+func (p Peer) GetConfig() wgtypes.PeerConfig {
+	cfg := wgtypes.PeerConfig{
+		ReplaceAllowedIPs: true,
+	}
+
+	if key, err := wgtypes.ParseKey(p.PublicKey); err == nil {
+		cfg.PublicKey = key
+	}
+	if p.PresharedKey != "" {
+		if psk, err := wgtypes.ParseKey(p.PresharedKey); err == nil {
+			cfg.PresharedKey = &psk
+		}
+	}
+
+	for _, ip := range p.IPs {
+		if _, ipNet, err := net.ParseCIDR(ip); err == nil {
+			cfg.AllowedIPs = append(cfg.AllowedIPs, *ipNet)
+		} else if parsed := net.ParseIP(ip); parsed != nil {
+			cfg.AllowedIPs = append(cfg.AllowedIPs, net.IPNet{IP: parsed, Mask: net.CIDRMask(32, 32)})
+		}
+	}
+
+	return cfg
+}